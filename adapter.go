@@ -4,26 +4,25 @@ import (
 	"crypto/md5"
 	"fmt"
 	"github.com/casbin/casbin/v2/model"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/casbin/casbin/v2/persist"
 
-	"github.com/guregu/dynamo"
-
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 type (
 	// Adapter structs holds dynamoDB config and service
 	Adapter struct {
-		Config         *aws.Config
-		Service        *dynamodb.DynamoDB
-		DB             *dynamo.DB
-		DataSourceName string
-		Context        aws.Context
+		*ContextAdapter
+		Context aws.Context
+
+		// tableName, billingMode and ttlAttribute configure CreateTable and
+		// WithAutoMigrate; see WithTableName, WithBillingMode and
+		// WithTTLAttribute.
+		tableName    string
+		billingMode  BillingMode
+		ttlAttribute string
 	}
 
 	CasbinRule struct {
@@ -39,20 +38,21 @@ type (
 )
 
 // NewAdapter is the constructor for adapter
-func NewAdapter(config *aws.Config, ds string, ctx aws.Context) (*Adapter, error) {
+func NewAdapter(config *aws.Config, ds string, ctx aws.Context, opts ...Option) (*Adapter, error) {
+	ca, err := NewContextAdapter(config, ds)
+	if err != nil {
+		return &Adapter{ContextAdapter: ca, Context: ctx}, err
+	}
+
 	a := &Adapter{
+		ContextAdapter: ca,
 		Context:        ctx,
-		Config:         config,
-		DataSourceName: ds,
 	}
-
-	s, err := session.NewSession(config)
-	if err != nil {
-		return a, err
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return a, err
+		}
 	}
-	a.Service = dynamodb.New(s, a.Config)
-	s, _ = session.NewSession()
-	a.DB = dynamo.New(s, a.Config)
 	return a, err
 }
 
@@ -90,15 +90,10 @@ func loadPolicyLine(line CasbinRule, model model.Model) {
 // !important: call Enforcer.LoadPolicy rather than call Adapter.LoadPolicy.
 // cause call Adapter.LoadPolicy multi times will repeat policys multi times.
 func (a *Adapter) LoadPolicy(model model.Model) error {
-	p, err := a.getAllItems()
+	err := a.LoadPolicyCtx(a.Context, model)
 	if err != nil {
 		panic(err)
 	}
-
-	for _, v := range p {
-		loadPolicyLine(v, model)
-	}
-
 	return err
 }
 
@@ -132,44 +127,7 @@ func savePolicyLine(ptype string, rule []string) CasbinRule {
 
 // save all policy
 func (a *Adapter) SavePolicy(model model.Model) error {
-	//IMPORTANT: No need use it now.
-	var lines []CasbinRule
-
-	for ptype, ast := range model["p"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			lines = append(lines, line)
-		}
-	}
-
-	for ptype, ast := range model["g"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			lines = append(lines, line)
-		}
-	}
-
-	_, err := a.saveItems(lines)
-	return err
-}
-
-func (a *Adapter) saveItems(rules []CasbinRule) (int, error) {
-	items := make([]interface{}, len(rules))
-
-	for i := 0; i < len(rules); i++ {
-		items[i] = rules[i]
-	}
-
-	return a.DB.Table(a.DataSourceName).Batch().Write().Put(items...).RunWithContext(a.Context)
-}
-
-func (a *Adapter) getAllItems() ([]CasbinRule, error) {
-	var rule []CasbinRule
-	err := a.DB.Table(a.DataSourceName).Scan().AllWithContext(a.Context, &rule)
-	if err != nil {
-		return nil, err
-	}
-	return rule, nil
+	return a.SavePolicyCtx(a.Context, model)
 }
 
 // This Err will return, if cond check is false
@@ -182,95 +140,41 @@ func isConditionalCheckErr(err error) bool {
 
 // AddPolicy adds a policy rule to the storage.
 func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
-	item := savePolicyLine(ptype, rule)
-	err := a.DB.Table(a.DataSourceName).Put(item).If("attribute_not_exists(ID)").RunWithContext(a.Context)
-	if isConditionalCheckErr(err) {
-		return nil
-	}
-	return err
+	return a.AddPolicyCtx(a.Context, sec, ptype, rule)
 }
 
 // AddPolicies adds a batch of policies to the storage.
 func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
-	// DynamoDB does not support batch writes with conditional statements, so we're using an error group to speed things
-	// up and to collect the errors
-	group, _ := errgroup.WithContext(a.Context)
-	for _, rule := range rules {
-		group.Go(func() error {
-			return a.AddPolicy(sec, ptype, rule)
-		})
-	}
-	return group.Wait()
+	return a.AddPoliciesCtx(a.Context, sec, ptype, rules)
 }
 
 // RemovePolicy removes a policy rule from the storage.
 func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	item := savePolicyLine(ptype, rule)
-	return a.DB.Table(a.DataSourceName).Delete("ID", item.ID).RunWithContext(a.Context)
+	return a.RemovePolicyCtx(a.Context, sec, ptype, rule)
 }
 
 // RemovePolicies removes a batch of rules from the storage.
 func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
-	keys := make([]dynamo.Keyed, len(rules))
-	for i, rule := range rules {
-		item := savePolicyLine(ptype, rule)
-		keys[i] = dynamo.Keys{item.ID, ptype}
-	}
-	wrote, err := a.DB.Table(a.DataSourceName).Batch().Write().Delete(keys...).RunWithContext(a.Context)
-	if wrote != len(rules) {
-		return fmt.Errorf("unexpected number of batch deletes; %d when expected %d", wrote, len(rules))
-	}
-	return err
+	return a.RemovePoliciesCtx(a.Context, sec, ptype, rules)
 }
 
 // RemoveFilteredPolicy removes policy rules that match the filter from the storage.
 func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
-	res, err := a.getAllItems()
-	if err != nil {
-		return err
-	}
-	line := &CasbinRule{PType: ptype}
+	return a.RemoveFilteredPolicyCtx(a.Context, sec, ptype, fieldIndex, fieldValues...)
+}
 
-	idx := fieldIndex + len(fieldValues)
-	if fieldIndex <= 0 && idx > 0 {
-		line.V0 = fieldValues[0-fieldIndex]
-	}
-	if fieldIndex <= 1 && idx > 1 {
-		line.V1 = fieldValues[1-fieldIndex]
-	}
-	if fieldIndex <= 2 && idx > 2 {
-		line.V2 = fieldValues[2-fieldIndex]
-	}
-	if fieldIndex <= 3 && idx > 3 {
-		line.V3 = fieldValues[3-fieldIndex]
-	}
-	if fieldIndex <= 4 && idx > 4 {
-		line.V4 = fieldValues[4-fieldIndex]
-	}
-	if fieldIndex <= 5 && idx > 5 {
-		line.V5 = fieldValues[5-fieldIndex]
-	}
-	items := make([]dynamo.Keyed, 0)
-	for _, item := range res {
-		if item.PType == line.PType {
-			if (line.V0 != "" && line.V0 != item.V0) ||
-				(line.V1 != "" && line.V1 != item.V1) ||
-				(line.V2 != "" && line.V2 != item.V2) ||
-				(line.V3 != "" && line.V3 != item.V3) ||
-				(line.V4 != "" && line.V4 != item.V4) ||
-				(line.V5 != "" && line.V5 != item.V5) {
-				continue
-			}
-			items = append(items, dynamo.Keys{item.ID}) // no sort key
-		}
-	}
+// UpdatePolicy updates a policy rule from storage.
+func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return a.UpdatePolicyCtx(a.Context, sec, ptype, oldRule, newRule)
+}
 
-	if len(items) == 0 {
-		return nil
-	}
-	cnt, err := a.DB.Table(a.DataSourceName).Batch("ID").Write().Delete(items...).RunWithContext(a.Context)
-	if cnt == len(items) {
-		return nil
-	}
-	return err
+// UpdatePolicies updates a batch of policy rules in storage.
+func (a *Adapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return a.UpdatePoliciesCtx(a.Context, sec, ptype, oldRules, newRules)
+}
+
+// UpdateFilteredPolicies deletes the rules matching the filter and replaces
+// them with newRules, returning the rules that were replaced.
+func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	return a.UpdateFilteredPoliciesCtx(a.Context, sec, ptype, newRules, fieldIndex, fieldValues...)
 }
@@ -0,0 +1,191 @@
+package dynacasbin
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/casbin/casbin/v2/model"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/guregu/dynamo"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ContextAdapter is a sibling of Adapter that implements persist.ContextAdapter,
+// threading a context.Context through to DynamoDB on every call instead of
+// relying on an adapter-wide field. It lets callers apply per-request
+// timeouts/cancellation (e.g. a short deadline on AddPolicyCtx from an HTTP
+// handler) without mutating shared adapter state.
+type ContextAdapter struct {
+	Config         *aws.Config
+	Service        *dynamodb.DynamoDB
+	DB             *dynamo.DB
+	DataSourceName string
+
+	// pTypeIndex is the name of a GSI over (PType, V0), set via
+	// WithPTypeIndex. When present, filtered loads/removals that pin PType
+	// (and optionally V0) Query it instead of scanning the whole table.
+	pTypeIndex string
+
+	// filtered is set once LoadFilteredPolicyCtx has been used to load a
+	// subset of the policy.
+	filtered bool
+
+	// DAX, when set via WithDAX, is a DynamoDB Accelerator client used for
+	// reads. Writes always go through DB so conditional writes keep working.
+	DAX *dynamo.DB
+
+	// readFromDAX toggles whether reads use DAX (when configured) or go
+	// straight to DynamoDB. It starts true whenever WithDAX succeeds, and is
+	// flipped off automatically if DAX returns a non-retryable error, or can
+	// be flipped off manually (e.g. ops disabling caching during debugging)
+	// via SetReadFromDAX. It's an atomic.Bool, not a plain bool, because a
+	// ContextAdapter is shared across concurrent LoadPolicyCtx/
+	// LoadFilteredPolicyCtx calls.
+	readFromDAX atomic.Bool
+}
+
+// NewContextAdapter is the constructor for ContextAdapter.
+func NewContextAdapter(config *aws.Config, ds string) (*ContextAdapter, error) {
+	a := &ContextAdapter{
+		Config:         config,
+		DataSourceName: ds,
+	}
+
+	s, err := session.NewSession(config)
+	if err != nil {
+		return a, err
+	}
+	a.Service = dynamodb.New(s, a.Config)
+	s, _ = session.NewSession()
+	a.DB = dynamo.New(s, a.Config)
+	return a, err
+}
+
+// LoadPolicyCtx loads all policy rules from the storage with context.
+func (a *ContextAdapter) LoadPolicyCtx(ctx context.Context, model model.Model) error {
+	p, err := a.getAllItemsCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range p {
+		loadPolicyLine(v, model)
+	}
+
+	return nil
+}
+
+// SavePolicyCtx saves all policy rules to the storage with context.
+func (a *ContextAdapter) SavePolicyCtx(ctx context.Context, model model.Model) error {
+	//IMPORTANT: No need use it now.
+	var lines []CasbinRule
+
+	for ptype, ast := range model["p"] {
+		for _, rule := range ast.Policy {
+			line := savePolicyLine(ptype, rule)
+			lines = append(lines, line)
+		}
+	}
+
+	for ptype, ast := range model["g"] {
+		for _, rule := range ast.Policy {
+			line := savePolicyLine(ptype, rule)
+			lines = append(lines, line)
+		}
+	}
+
+	_, err := a.saveItemsCtx(ctx, lines)
+	return err
+}
+
+func (a *ContextAdapter) saveItemsCtx(ctx context.Context, rules []CasbinRule) (int, error) {
+	items := make([]interface{}, len(rules))
+
+	for i := 0; i < len(rules); i++ {
+		items[i] = rules[i]
+	}
+
+	return a.DB.Table(a.DataSourceName).Batch().Write().Put(items...).RunWithContext(ctx)
+}
+
+func (a *ContextAdapter) getAllItemsCtx(ctx context.Context) ([]CasbinRule, error) {
+	var rule []CasbinRule
+	err := a.readTable(func(table dynamo.Table) error {
+		rule = nil
+		return table.Scan().AllWithContext(ctx, &rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// AddPolicyCtx adds a policy rule to the storage with context.
+func (a *ContextAdapter) AddPolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
+	item := savePolicyLine(ptype, rule)
+	err := a.DB.Table(a.DataSourceName).Put(item).If("attribute_not_exists(ID)").RunWithContext(ctx)
+	if isConditionalCheckErr(err) {
+		return nil
+	}
+	return err
+}
+
+// AddPoliciesCtx adds a batch of policies to the storage with context.
+func (a *ContextAdapter) AddPoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) error {
+	// DynamoDB does not support batch writes with conditional statements, so we're using an error group to speed things
+	// up and to collect the errors
+	group, ctx := errgroup.WithContext(ctx)
+	for _, rule := range rules {
+		rule := rule
+		group.Go(func() error {
+			return a.AddPolicyCtx(ctx, sec, ptype, rule)
+		})
+	}
+	return group.Wait()
+}
+
+// RemovePolicyCtx removes a policy rule from the storage with context.
+func (a *ContextAdapter) RemovePolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
+	item := savePolicyLine(ptype, rule)
+	return a.DB.Table(a.DataSourceName).Delete("ID", item.ID).RunWithContext(ctx)
+}
+
+// RemovePoliciesCtx removes a batch of rules from the storage with context.
+func (a *ContextAdapter) RemovePoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) error {
+	keys := make([]dynamo.Keyed, len(rules))
+	for i, rule := range rules {
+		item := savePolicyLine(ptype, rule)
+		keys[i] = dynamo.Keys{item.ID} // CasbinRule only declares a hash key
+	}
+	wrote, err := a.DB.Table(a.DataSourceName).Batch().Write().Delete(keys...).RunWithContext(ctx)
+	if wrote != len(rules) {
+		return fmt.Errorf("unexpected number of batch deletes; %d when expected %d", wrote, len(rules))
+	}
+	return err
+}
+
+// RemoveFilteredPolicyCtx removes policy rules that match the filter from the storage with context.
+func (a *ContextAdapter) RemoveFilteredPolicyCtx(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	res, err := a.loadFilteredItemsCtx(ctx, fieldFilter(ptype, fieldIndex, fieldValues))
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	items := make([]dynamo.Keyed, len(res))
+	for i, item := range res {
+		items[i] = dynamo.Keys{item.ID} // no sort key
+	}
+	cnt, err := a.DB.Table(a.DataSourceName).Batch("ID").Write().Delete(items...).RunWithContext(ctx)
+	if cnt == len(items) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package dynacasbin
+
+import "testing"
+
+func TestFilterExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *Filter
+		wantExpr string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no fields constrained",
+			filter:   &Filter{},
+			wantExpr: "",
+			wantArgs: nil,
+		},
+		{
+			name:     "a single field with one value",
+			filter:   &Filter{PType: []string{"p"}},
+			wantExpr: "('PType' = ?)",
+			wantArgs: []interface{}{"p"},
+		},
+		{
+			name:     "values within a field are OR'd",
+			filter:   &Filter{V0: []string{"alice", "bob"}},
+			wantExpr: "('V0' = ? OR 'V0' = ?)",
+			wantArgs: []interface{}{"alice", "bob"},
+		},
+		{
+			name:     "fields are AND'd in declaration order",
+			filter:   &Filter{PType: []string{"p"}, V1: []string{"data1"}},
+			wantExpr: "('PType' = ?) AND ('V1' = ?)",
+			wantArgs: []interface{}{"p", "data1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotExpr, gotArgs := tt.filter.expression()
+			if gotExpr != tt.wantExpr {
+				t.Errorf("got expr %q, want %q", gotExpr, tt.wantExpr)
+			}
+			if len(gotArgs) != len(tt.wantArgs) {
+				t.Fatalf("got args %v, want %v", gotArgs, tt.wantArgs)
+			}
+			for i, a := range gotArgs {
+				if a != tt.wantArgs[i] {
+					t.Errorf("arg %d: got %v, want %v", i, a, tt.wantArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterCanUseIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"PType and V0 only", &Filter{PType: []string{"p"}, V0: []string{"alice"}}, true},
+		{"PType only", &Filter{PType: []string{"p"}}, true},
+		{"no PType", &Filter{V0: []string{"alice"}}, false},
+		{"more than one PType", &Filter{PType: []string{"p", "g"}}, false},
+		{"more than one V0", &Filter{PType: []string{"p"}, V0: []string{"alice", "bob"}}, false},
+		{"a constrained field beyond V0", &Filter{PType: []string{"p"}, V1: []string{"data1"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.canUseIndex(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		fieldIndex int
+		fieldVals  []string
+		want       *Filter
+	}{
+		{
+			name:       "fieldIndex 0 pins V0",
+			fieldIndex: 0,
+			fieldVals:  []string{"alice"},
+			want:       &Filter{PType: []string{"p"}, V0: []string{"alice"}},
+		},
+		{
+			name:       "fieldIndex 1 pins V1, leaving V0 unconstrained",
+			fieldIndex: 1,
+			fieldVals:  []string{"data1"},
+			want:       &Filter{PType: []string{"p"}, V1: []string{"data1"}},
+		},
+		{
+			name:       "consecutive values starting at fieldIndex pin consecutive fields",
+			fieldIndex: 0,
+			fieldVals:  []string{"alice", "data1"},
+			want:       &Filter{PType: []string{"p"}, V0: []string{"alice"}, V1: []string{"data1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fieldFilter("p", tt.fieldIndex, tt.fieldVals)
+			gotExpr, _ := got.expression()
+			wantExpr, _ := tt.want.expression()
+			if gotExpr != wantExpr {
+				t.Errorf("got filter producing %q, want %q", gotExpr, wantExpr)
+			}
+		})
+	}
+}
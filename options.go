@@ -0,0 +1,17 @@
+package dynacasbin
+
+// Option configures an Adapter built by NewAdapter. Options run in order
+// after the adapter's DynamoDB clients have been created, and may fail
+// (e.g. WithDAX needs to dial the cluster).
+type Option func(*Adapter) error
+
+// WithPTypeIndex names a Global Secondary Index over (PType, V0) for
+// NewAdapter to use. When set, LoadFilteredPolicy and RemoveFilteredPolicy
+// Query that index instead of scanning the whole table whenever the filter
+// pins PType (and optionally V0).
+func WithPTypeIndex(name string) Option {
+	return func(a *Adapter) error {
+		a.pTypeIndex = name
+		return nil
+	}
+}
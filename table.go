@@ -0,0 +1,138 @@
+package dynacasbin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/guregu/dynamo"
+)
+
+// BillingMode selects between on-demand and provisioned throughput when
+// creating a table with CreateTable.
+type BillingMode string
+
+const (
+	// BillingModeProvisioned creates the table with a fixed read/write
+	// capacity. This is the default.
+	BillingModeProvisioned BillingMode = "PROVISIONED"
+	// BillingModeOnDemand creates the table with pay-per-request billing.
+	BillingModeOnDemand BillingMode = "PAY_PER_REQUEST"
+)
+
+// WithTableName overrides the table name CreateTable (and WithAutoMigrate)
+// operate on; it defaults to the data source name given to NewAdapter.
+func WithTableName(name string) Option {
+	return func(a *Adapter) error {
+		a.tableName = name
+		return nil
+	}
+}
+
+// WithBillingMode selects on-demand vs provisioned throughput for a table
+// created by CreateTable. Defaults to BillingModeProvisioned.
+func WithBillingMode(mode BillingMode) Option {
+	return func(a *Adapter) error {
+		a.billingMode = mode
+		return nil
+	}
+}
+
+// WithTTLAttribute enables DynamoDB's native TTL on the given attribute when
+// CreateTable provisions the table.
+func WithTTLAttribute(attribute string) Option {
+	return func(a *Adapter) error {
+		a.ttlAttribute = attribute
+		return nil
+	}
+}
+
+// WithAutoMigrate makes NewAdapter ensure the table matches the schema this
+// adapter expects before returning: creating it if it doesn't exist (per
+// WithTableName/WithBillingMode/WithTTLAttribute/WithPTypeIndex), or adding
+// a missing PType GSI to an existing table. List it after any of those
+// options so they've already been applied when it runs.
+func WithAutoMigrate() Option {
+	return func(a *Adapter) error {
+		return a.migrateTable(context.Background())
+	}
+}
+
+func (a *Adapter) tableNameForSchema() string {
+	if a.tableName != "" {
+		return a.tableName
+	}
+	return a.DataSourceName
+}
+
+// CreateTable provisions the DynamoDB table this adapter expects: a hash key
+// on ID, and (when WithPTypeIndex is given) a GSI over (PType, V0) backing
+// the FilteredAdapter methods.
+func (a *Adapter) CreateTable(ctx context.Context, opts ...Option) error {
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return err
+		}
+	}
+
+	name := a.tableNameForSchema()
+	ct := a.DB.CreateTable(name, CasbinRule{}).
+		OnDemand(a.billingMode == BillingModeOnDemand)
+	if a.pTypeIndex != "" {
+		ct = ct.Index(pTypeIndex(a.pTypeIndex))
+	}
+	if err := ct.RunWithContext(ctx); err != nil {
+		return fmt.Errorf("dynacasbin: creating table %s: %w", name, err)
+	}
+
+	if a.ttlAttribute != "" {
+		return a.DB.Table(name).UpdateTTL(a.ttlAttribute, true).RunWithContext(ctx)
+	}
+	return nil
+}
+
+// migrateTable creates the table if it's missing, or adds the configured
+// PType GSI to an existing table that lacks one.
+func (a *Adapter) migrateTable(ctx context.Context) error {
+	name := a.tableNameForSchema()
+
+	desc, err := a.DB.Table(name).Describe().RunWithContext(ctx)
+	if err != nil {
+		var rf awserr.RequestFailure
+		if errors.As(err, &rf) && rf.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return a.CreateTable(ctx)
+		}
+		return fmt.Errorf("dynacasbin: describing table %s: %w", name, err)
+	}
+
+	if a.pTypeIndex == "" || hasGSI(desc.GSI, a.pTypeIndex) {
+		return nil
+	}
+	_, err = a.DB.Table(name).UpdateTable().CreateIndex(pTypeIndex(a.pTypeIndex)).RunWithContext(ctx)
+	return err
+}
+
+// hasGSI reports whether gsis already contains an index named name.
+func hasGSI(gsis []dynamo.Index, name string) bool {
+	for _, gsi := range gsis {
+		if gsi.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pTypeIndex describes the GSI over (PType, V0) that backs server-side
+// filtering, projecting every attribute so loads don't need a second fetch.
+func pTypeIndex(name string) dynamo.Index {
+	return dynamo.Index{
+		Name:           name,
+		HashKey:        "PType",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "V0",
+		RangeKeyType:   dynamo.StringType,
+		ProjectionType: dynamo.AllProjection,
+	}
+}
@@ -0,0 +1,31 @@
+package dynacasbin
+
+import (
+	"testing"
+
+	"github.com/guregu/dynamo"
+)
+
+func TestHasGSI(t *testing.T) {
+	gsis := []dynamo.Index{
+		{Name: "other-index"},
+		{Name: "ptype-index"},
+	}
+
+	tests := []struct {
+		name string
+		gsis []dynamo.Index
+		want bool
+	}{
+		{"finds a matching index", gsis, true},
+		{"reports no match among other indexes", []dynamo.Index{{Name: "other-index"}}, false},
+		{"reports no match with no indexes", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasGSI(tt.gsis, "ptype-index"); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
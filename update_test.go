@@ -0,0 +1,205 @@
+package dynacasbin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func cancellationReason(code string) *dynamodb.CancellationReason {
+	if code == "" {
+		return &dynamodb.CancellationReason{Code: aws.String("None")}
+	}
+	return &dynamodb.CancellationReason{Code: aws.String(code)}
+}
+
+func TestUpdateTransactErr(t *testing.T) {
+	oldRules := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}
+
+	t.Run("passes through non-transaction errors", func(t *testing.T) {
+		err := errors.New("boom")
+		if got := updateTransactErr(err, oldRules); got != err {
+			t.Errorf("got %v, want %v unchanged", got, err)
+		}
+	})
+
+	t.Run("passes through nil", func(t *testing.T) {
+		if got := updateTransactErr(nil, oldRules); got != nil {
+			t.Errorf("got %v, want nil", got)
+		}
+	})
+
+	t.Run("maps a failed conditional check to its rule", func(t *testing.T) {
+		tce := &dynamodb.TransactionCanceledException{
+			CancellationReasons: []*dynamodb.CancellationReason{
+				cancellationReason(""),
+				cancellationReason("ConditionalCheckFailed"),
+				cancellationReason(""),
+				cancellationReason(""),
+			},
+		}
+
+		err := updateTransactErr(tce, oldRules)
+
+		var ue *UpdateError
+		if !errors.As(err, &ue) {
+			t.Fatalf("got %v, want an *UpdateError", err)
+		}
+		// reason index 1 is the Put half of oldRules[0]'s pair (Delete=0, Put=1)
+		if !reflect.DeepEqual(ue.Rule, oldRules[0]) {
+			t.Errorf("got rule %v, want %v", ue.Rule, oldRules[0])
+		}
+	})
+
+	t.Run("ignores a transaction cancelled for other reasons", func(t *testing.T) {
+		tce := &dynamodb.TransactionCanceledException{
+			CancellationReasons: []*dynamodb.CancellationReason{
+				cancellationReason("TransactionConflict"),
+				cancellationReason("TransactionConflict"),
+			},
+		}
+
+		if got := updateTransactErr(tce, oldRules); got != tce {
+			t.Errorf("got %v, want the original error unchanged", got)
+		}
+	})
+}
+
+func TestUpdateFilteredTransactErr(t *testing.T) {
+	opRules := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"carol", "data3", "read"}}
+
+	t.Run("maps by direct index, not a pair", func(t *testing.T) {
+		tce := &dynamodb.TransactionCanceledException{
+			CancellationReasons: []*dynamodb.CancellationReason{
+				cancellationReason(""),
+				cancellationReason(""),
+				cancellationReason("ConditionalCheckFailed"),
+			},
+		}
+
+		err := updateFilteredTransactErr(tce, opRules)
+
+		var ue *UpdateError
+		if !errors.As(err, &ue) {
+			t.Fatalf("got %v, want an *UpdateError", err)
+		}
+		if !reflect.DeepEqual(ue.Rule, opRules[2]) {
+			t.Errorf("got rule %v, want %v", ue.Rule, opRules[2])
+		}
+	})
+
+	t.Run("passes through non-transaction errors", func(t *testing.T) {
+		err := errors.New("boom")
+		if got := updateFilteredTransactErr(err, opRules); got != err {
+			t.Errorf("got %v, want %v unchanged", got, err)
+		}
+	})
+}
+
+func TestPlanReplaceOps(t *testing.T) {
+	t.Run("deletes old and puts new when they don't overlap", func(t *testing.T) {
+		oldRules := [][]string{{"alice", "data1", "read"}}
+		newRules := [][]string{{"bob", "data1", "read"}}
+
+		ops := planReplaceOps("p", oldRules, newRules)
+
+		if len(ops) != 2 {
+			t.Fatalf("got %d ops, want 2", len(ops))
+		}
+		var deletes, puts int
+		for _, op := range ops {
+			if op.delete {
+				deletes++
+			} else {
+				puts++
+			}
+		}
+		if deletes != 1 || puts != 1 {
+			t.Errorf("got %d deletes and %d puts, want 1 and 1", deletes, puts)
+		}
+	})
+
+	t.Run("drops a rule present in both old and new", func(t *testing.T) {
+		rule := []string{"alice", "data1", "read"}
+		oldRules := [][]string{rule, {"bob", "data2", "write"}}
+		newRules := [][]string{rule, {"carol", "data3", "read"}}
+
+		ops := planReplaceOps("p", oldRules, newRules)
+
+		if len(ops) != 2 {
+			t.Fatalf("got %d ops, want 2 (the unchanged rule should be skipped): %+v", len(ops), ops)
+		}
+		for _, op := range ops {
+			if reflect.DeepEqual(op.rule, rule) {
+				t.Errorf("unchanged rule %v should not appear in the op list", rule)
+			}
+		}
+	})
+
+	t.Run("handles independent lengths", func(t *testing.T) {
+		oldRules := [][]string{{"a", "d1", "read"}, {"b", "d2", "read"}, {"c", "d3", "read"}}
+		newRules := [][]string{{"d", "d1", "read"}}
+
+		ops := planReplaceOps("p", oldRules, newRules)
+
+		if len(ops) != len(oldRules)+len(newRules) {
+			t.Fatalf("got %d ops, want %d", len(ops), len(oldRules)+len(newRules))
+		}
+	})
+}
+
+func TestChunkReplaceOps(t *testing.T) {
+	t.Run("empty input produces no chunks", func(t *testing.T) {
+		if chunks := chunkReplaceOps(nil); len(chunks) != 0 {
+			t.Errorf("got %d chunks, want 0", len(chunks))
+		}
+	})
+
+	t.Run("splits into groups of at most maxTransactItems", func(t *testing.T) {
+		ops := make([]replaceOp, maxTransactItems+1)
+
+		chunks := chunkReplaceOps(ops)
+
+		if len(chunks) != 2 {
+			t.Fatalf("got %d chunks, want 2", len(chunks))
+		}
+		if len(chunks[0]) != maxTransactItems {
+			t.Errorf("got first chunk of size %d, want %d", len(chunks[0]), maxTransactItems)
+		}
+		if len(chunks[1]) != 1 {
+			t.Errorf("got second chunk of size %d, want 1", len(chunks[1]))
+		}
+	})
+
+	t.Run("a single short chunk stays whole", func(t *testing.T) {
+		ops := make([]replaceOp, 3)
+
+		chunks := chunkReplaceOps(ops)
+
+		if len(chunks) != 1 || len(chunks[0]) != 3 {
+			t.Fatalf("got %v, want a single chunk of 3", chunks)
+		}
+	})
+}
+
+func TestRuleValues(t *testing.T) {
+	tests := []struct {
+		name string
+		line CasbinRule
+		want []string
+	}{
+		{"trims trailing empty fields", CasbinRule{V0: "alice", V1: "data1", V2: "read"}, []string{"alice", "data1", "read"}},
+		{"keeps an empty field in the middle", CasbinRule{V0: "alice", V2: "read"}, []string{"alice", "", "read"}},
+		{"all empty yields an empty slice", CasbinRule{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleValues(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package dynacasbin is the aws-sdk-go-v2 / guregu/dynamo/v2 sibling of
+// github.com/Project-Plato/dynacasbin. It implements the same policy storage
+// on top of the newer SDKs: every persist method has a ...Ctx variant that
+// takes a context.Context directly (there is no aws.Context adapter field to
+// thread through; the methods without one use context.Background()), and
+// errors are the SDK's structured types rather than string-code matches.
+//
+// Existing callers on the v1 package are unaffected; this package is for new
+// integrations that want native context and v2-only features such as
+// ReturnValuesOnConditionCheckFailure.
+package dynacasbin
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/guregu/dynamo/v2"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type (
+	// Adapter holds the DynamoDB clients and config used to store policies.
+	// Each persist method has a ...Ctx variant taking a context.Context; the
+	// methods without one use context.Background(). There is no adapter-wide
+	// context field to thread through.
+	Adapter struct {
+		Config         aws.Config
+		Service        *dynamodb.Client
+		DB             *dynamo.DB
+		DataSourceName string
+
+		// pTypeIndex is the name of a GSI over (PType, V0), set via
+		// WithPTypeIndex. When present, filtered loads/removals that pin
+		// PType (and optionally V0) Query it instead of scanning the whole
+		// table.
+		pTypeIndex string
+
+		// filtered is set once LoadFilteredPolicy has been used to load a
+		// subset of the policy.
+		filtered bool
+	}
+
+	CasbinRule struct {
+		ID    string `dynamo:"ID,hash"`
+		PType string `dynamo:"PType"`
+		V0    string `dynamo:"V0"`
+		V1    string `dynamo:"V1"`
+		V2    string `dynamo:"V2"`
+		V3    string `dynamo:"V3"`
+		V4    string `dynamo:"V4"`
+		V5    string `dynamo:"V5"`
+	}
+)
+
+// DuplicatePolicyError reports that AddPolicyCtx (or AddPoliciesCtx) rejected
+// a rule because it already exists, and carries the row that was already
+// stored, recovered from DynamoDB's ReturnValuesOnConditionCheckFailure.
+type DuplicatePolicyError struct {
+	Existing CasbinRule
+}
+
+func (e *DuplicatePolicyError) Error() string {
+	return fmt.Sprintf("dynacasbin: policy rule already exists: %v", ruleValues(e.Existing))
+}
+
+// NewAdapter is the constructor for Adapter.
+func NewAdapter(config aws.Config, ds string, opts ...Option) (*Adapter, error) {
+	a := &Adapter{
+		Config:         config,
+		Service:        dynamodb.NewFromConfig(config),
+		DataSourceName: ds,
+	}
+	a.DB = dynamo.New(config)
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// use md5(line) to prevent overwrites of an existing item
+func generateID(line CasbinRule) string {
+	data := []byte(fmt.Sprint(line))
+	has := md5.Sum(data)
+	return fmt.Sprintf("%x", has)
+}
+
+func loadPolicyLine(line CasbinRule, model model.Model) {
+	lineText := line.PType
+	if line.V0 != "" {
+		lineText += ", " + line.V0
+	}
+	if line.V1 != "" {
+		lineText += ", " + line.V1
+	}
+	if line.V2 != "" {
+		lineText += ", " + line.V2
+	}
+	if line.V3 != "" {
+		lineText += ", " + line.V3
+	}
+	if line.V4 != "" {
+		lineText += ", " + line.V4
+	}
+	if line.V5 != "" {
+		lineText += ", " + line.V5
+	}
+
+	persist.LoadPolicyLine(lineText, model)
+}
+
+func savePolicyLine(ptype string, rule []string) CasbinRule {
+	line := CasbinRule{}
+
+	line.PType = ptype
+	if len(rule) > 0 {
+		line.V0 = rule[0]
+	}
+	if len(rule) > 1 {
+		line.V1 = rule[1]
+	}
+	if len(rule) > 2 {
+		line.V2 = rule[2]
+	}
+	if len(rule) > 3 {
+		line.V3 = rule[3]
+	}
+	if len(rule) > 4 {
+		line.V4 = rule[4]
+	}
+	if len(rule) > 5 {
+		line.V5 = rule[5]
+	}
+
+	//set md5 id
+	line.ID = generateID(line)
+	return line
+}
+
+// LoadPolicy loads all policy rules from the storage.
+func (a *Adapter) LoadPolicy(model model.Model) error {
+	return a.LoadPolicyCtx(context.Background(), model)
+}
+
+// LoadPolicyCtx loads all policy rules from the storage with context.
+func (a *Adapter) LoadPolicyCtx(ctx context.Context, model model.Model) error {
+	p, err := a.getAllItemsCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range p {
+		loadPolicyLine(v, model)
+	}
+
+	return nil
+}
+
+// SavePolicy saves all policy rules to the storage.
+func (a *Adapter) SavePolicy(model model.Model) error {
+	return a.SavePolicyCtx(context.Background(), model)
+}
+
+// SavePolicyCtx saves all policy rules to the storage with context.
+func (a *Adapter) SavePolicyCtx(ctx context.Context, model model.Model) error {
+	var lines []CasbinRule
+
+	for ptype, ast := range model["p"] {
+		for _, rule := range ast.Policy {
+			line := savePolicyLine(ptype, rule)
+			lines = append(lines, line)
+		}
+	}
+
+	for ptype, ast := range model["g"] {
+		for _, rule := range ast.Policy {
+			line := savePolicyLine(ptype, rule)
+			lines = append(lines, line)
+		}
+	}
+
+	_, err := a.saveItemsCtx(ctx, lines)
+	return err
+}
+
+func (a *Adapter) saveItemsCtx(ctx context.Context, rules []CasbinRule) (int, error) {
+	items := make([]interface{}, len(rules))
+
+	for i := 0; i < len(rules); i++ {
+		items[i] = rules[i]
+	}
+
+	return a.DB.Table(a.DataSourceName).Batch().Write().Put(items...).Run(ctx)
+}
+
+func (a *Adapter) getAllItemsCtx(ctx context.Context) ([]CasbinRule, error) {
+	var rule []CasbinRule
+	err := a.DB.Table(a.DataSourceName).Scan().All(ctx, &rule)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// AddPolicy adds a policy rule to the storage. If the rule already exists,
+// it returns a *DuplicatePolicyError carrying the row that was already
+// stored.
+func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return a.AddPolicyCtx(context.Background(), sec, ptype, rule)
+}
+
+// AddPolicyCtx adds a policy rule to the storage with context. If the rule
+// already exists, it returns a *DuplicatePolicyError carrying the row that
+// was already stored, recovered via ReturnValuesOnConditionCheckFailure.
+func (a *Adapter) AddPolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
+	item := savePolicyLine(ptype, rule)
+	encoded, err := dynamo.MarshalItem(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.Service.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                           &a.DataSourceName,
+		Item:                                encoded,
+		ConditionExpression:                 aws.String("attribute_not_exists(ID)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		var existing CasbinRule
+		if uerr := dynamo.UnmarshalItem(ccf.Item, &existing); uerr == nil {
+			return &DuplicatePolicyError{Existing: existing}
+		}
+		return &DuplicatePolicyError{Existing: item}
+	}
+	return err
+}
+
+// AddPolicies adds a batch of policies to the storage.
+func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	return a.AddPoliciesCtx(context.Background(), sec, ptype, rules)
+}
+
+// AddPoliciesCtx adds a batch of policies to the storage with context.
+func (a *Adapter) AddPoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) error {
+	// DynamoDB does not support batch writes with conditional statements, so we're using an error group to speed things
+	// up and to collect the errors
+	group, ctx := errgroup.WithContext(ctx)
+	for _, rule := range rules {
+		rule := rule
+		group.Go(func() error {
+			return a.AddPolicyCtx(ctx, sec, ptype, rule)
+		})
+	}
+	return group.Wait()
+}
+
+// RemovePolicy removes a policy rule from the storage.
+func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return a.RemovePolicyCtx(context.Background(), sec, ptype, rule)
+}
+
+// RemovePolicyCtx removes a policy rule from the storage with context.
+func (a *Adapter) RemovePolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
+	item := savePolicyLine(ptype, rule)
+	return a.DB.Table(a.DataSourceName).Delete("ID", item.ID).Run(ctx)
+}
+
+// RemovePolicies removes a batch of rules from the storage.
+func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	return a.RemovePoliciesCtx(context.Background(), sec, ptype, rules)
+}
+
+// RemovePoliciesCtx removes a batch of rules from the storage with context.
+func (a *Adapter) RemovePoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) error {
+	keys := make([]dynamo.Keyed, len(rules))
+	for i, rule := range rules {
+		item := savePolicyLine(ptype, rule)
+		keys[i] = dynamo.Keys{item.ID} // CasbinRule only declares a hash key
+	}
+	wrote, err := a.DB.Table(a.DataSourceName).Batch().Write().Delete(keys...).Run(ctx)
+	if wrote != len(rules) {
+		return fmt.Errorf("unexpected number of batch deletes; %d when expected %d", wrote, len(rules))
+	}
+	return err
+}
+
+// RemoveFilteredPolicy removes policy rules that match the filter from the storage.
+func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	return a.RemoveFilteredPolicyCtx(context.Background(), sec, ptype, fieldIndex, fieldValues...)
+}
+
+// RemoveFilteredPolicyCtx removes policy rules that match the filter from the storage with context.
+func (a *Adapter) RemoveFilteredPolicyCtx(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	res, err := a.loadFilteredItemsCtx(ctx, fieldFilter(ptype, fieldIndex, fieldValues))
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	items := make([]dynamo.Keyed, len(res))
+	for i, item := range res {
+		items[i] = dynamo.Keys{item.ID} // no sort key
+	}
+	cnt, err := a.DB.Table(a.DataSourceName).Batch("ID").Write().Delete(items...).Run(ctx)
+	if cnt == len(items) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package dynacasbin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/guregu/dynamo/v2"
+)
+
+// Filter narrows LoadFilteredPolicy (and RemoveFilteredPolicy, internally) to
+// rules matching the given values. Values within a field are OR'd together;
+// fields are AND'd. An empty field is not constrained.
+type Filter struct {
+	PType []string
+	V0    []string
+	V1    []string
+	V2    []string
+	V3    []string
+	V4    []string
+	V5    []string
+}
+
+// expression renders the filter as a DynamoDB FilterExpression (or key
+// condition filter) plus its positional args.
+func (f *Filter) expression() (string, []interface{}) {
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"PType", f.PType},
+		{"V0", f.V0},
+		{"V1", f.V1},
+		{"V2", f.V2},
+		{"V3", f.V3},
+		{"V4", f.V4},
+		{"V5", f.V5},
+	}
+
+	var clauses []string
+	var args []interface{}
+	for _, field := range fields {
+		if len(field.values) == 0 {
+			continue
+		}
+		ors := make([]string, len(field.values))
+		for i, v := range field.values {
+			ors[i] = fmt.Sprintf("'%s' = ?", field.name)
+			args = append(args, v)
+		}
+		clauses = append(clauses, "("+strings.Join(ors, " OR ")+")")
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// fieldFilter turns the (fieldIndex, fieldValues) convention used by
+// RemoveFilteredPolicy into the equivalent Filter. An empty value leaves its
+// field unconstrained.
+func fieldFilter(ptype string, fieldIndex int, fieldValues []string) *Filter {
+	f := &Filter{PType: []string{ptype}}
+
+	idx := fieldIndex + len(fieldValues)
+	if fieldIndex <= 0 && idx > 0 && fieldValues[0-fieldIndex] != "" {
+		f.V0 = []string{fieldValues[0-fieldIndex]}
+	}
+	if fieldIndex <= 1 && idx > 1 && fieldValues[1-fieldIndex] != "" {
+		f.V1 = []string{fieldValues[1-fieldIndex]}
+	}
+	if fieldIndex <= 2 && idx > 2 && fieldValues[2-fieldIndex] != "" {
+		f.V2 = []string{fieldValues[2-fieldIndex]}
+	}
+	if fieldIndex <= 3 && idx > 3 && fieldValues[3-fieldIndex] != "" {
+		f.V3 = []string{fieldValues[3-fieldIndex]}
+	}
+	if fieldIndex <= 4 && idx > 4 && fieldValues[4-fieldIndex] != "" {
+		f.V4 = []string{fieldValues[4-fieldIndex]}
+	}
+	if fieldIndex <= 5 && idx > 5 && fieldValues[5-fieldIndex] != "" {
+		f.V5 = []string{fieldValues[5-fieldIndex]}
+	}
+	return f
+}
+
+// canUseIndex reports whether f can be satisfied with a Query against the
+// PType index instead of a table Scan: PType must be pinned to one value,
+// and V0 to at most one, with no other fields constrained.
+func (f *Filter) canUseIndex() bool {
+	return len(f.PType) == 1 && len(f.V0) <= 1 &&
+		len(f.V1) == 0 && len(f.V2) == 0 && len(f.V3) == 0 && len(f.V4) == 0 && len(f.V5) == 0
+}
+
+// loadFilteredItemsCtx fetches the rules matching filter, querying the
+// configured PType index when possible and falling back to a filtered Scan
+// of the whole table otherwise.
+func (a *Adapter) loadFilteredItemsCtx(ctx context.Context, filter *Filter) ([]CasbinRule, error) {
+	var rules []CasbinRule
+
+	table := a.DB.Table(a.DataSourceName)
+	if a.pTypeIndex != "" && filter.canUseIndex() {
+		query := table.Get("PType", filter.PType[0]).Index(a.pTypeIndex)
+		if len(filter.V0) == 1 {
+			query = query.Range("V0", dynamo.Equal, filter.V0[0])
+		}
+		if err := query.All(ctx, &rules); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}
+
+	scan := table.Scan()
+	if expr, args := filter.expression(); expr != "" {
+		scan = scan.Filter(expr, args...)
+	}
+	if err := scan.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadFilteredPolicy loads only the policy rules matching filter, which must
+// be a *Filter. When a PType index has been configured (see WithPTypeIndex)
+// and the filter pins PType (and optionally V0), the rules are fetched with
+// a Query against that index instead of a full table Scan.
+func (a *Adapter) LoadFilteredPolicy(model model.Model, filter interface{}) error {
+	return a.LoadFilteredPolicyCtx(context.Background(), model, filter)
+}
+
+// LoadFilteredPolicyCtx loads only the policy rules matching filter, which
+// must be a *Filter, with context. When a PType index has been configured
+// (see WithPTypeIndex) and the filter pins PType (and optionally V0), the
+// rules are fetched with a Query against that index instead of a full table
+// Scan.
+func (a *Adapter) LoadFilteredPolicyCtx(ctx context.Context, model model.Model, filter interface{}) error {
+	f, ok := filter.(*Filter)
+	if !ok {
+		return fmt.Errorf("dynacasbin: invalid filter type: expected *dynacasbin.Filter, got %T", filter)
+	}
+
+	rules, err := a.loadFilteredItemsCtx(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range rules {
+		loadPolicyLine(v, model)
+	}
+	a.filtered = true
+	return nil
+}
+
+// IsFilteredCtx returns true if LoadFilteredPolicyCtx has been used to load a
+// subset of the policy rather than the full table.
+func (a *Adapter) IsFilteredCtx(ctx context.Context) bool {
+	return a.filtered
+}
+
+// IsFiltered returns true if LoadFilteredPolicy has been used to load a
+// subset of the policy rather than the full table.
+func (a *Adapter) IsFiltered() bool {
+	return a.IsFilteredCtx(context.Background())
+}
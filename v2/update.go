@@ -0,0 +1,250 @@
+package dynacasbin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTransactItems is DynamoDB's hard limit on the number of items in a
+// single TransactWriteItems call. Each rule update consumes two items (a
+// conditional Delete of the old row and a conditional Put of the new one),
+// so at most maxTransactItems/2 rules can be updated per transaction.
+const maxTransactItems = 25
+
+// UpdateError reports that a batched update was rejected because one of its
+// conditional checks failed: an old rule to delete no longer existed, or a
+// new rule to put already did, typically because another writer changed the
+// table concurrently. For UpdatePolicy and UpdatePolicies, Rule is the old
+// rule of the failed pair; for UpdateFilteredPolicies it is the rule whose
+// own Delete or Put failed.
+type UpdateError struct {
+	Rule []string
+	Err  error
+}
+
+func (e *UpdateError) Error() string {
+	return fmt.Sprintf("dynacasbin: update rejected for rule %v, it may have changed concurrently: %v", e.Rule, e.Err)
+}
+
+func (e *UpdateError) Unwrap() error {
+	return e.Err
+}
+
+// updateTransactErr maps a transaction failure back to the old rule (in
+// oldRules, matching Delete/Put submission order) whose conditional check
+// failed, if any.
+func updateTransactErr(err error, oldRules [][]string) error {
+	var tce *types.TransactionCanceledException
+	if err == nil || !errors.As(err, &tce) {
+		return err
+	}
+	for i, reason := range tce.CancellationReasons {
+		if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+			return &UpdateError{Rule: oldRules[i/2], Err: err}
+		}
+	}
+	return err
+}
+
+// UpdatePolicy updates a policy rule from storage. The old row is deleted
+// and the new one inserted atomically, so readers never observe a state
+// where both or neither exist.
+func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return a.UpdatePolicyCtx(context.Background(), sec, ptype, oldRule, newRule)
+}
+
+// UpdatePolicyCtx updates a policy rule from storage with context. The old
+// row is deleted and the new one inserted atomically, so readers never
+// observe a state where both or neither exist.
+func (a *Adapter) UpdatePolicyCtx(ctx context.Context, sec string, ptype string, oldRule, newRule []string) error {
+	oldItem := savePolicyLine(ptype, oldRule)
+	newItem := savePolicyLine(ptype, newRule)
+
+	if oldItem.ID == newItem.ID {
+		// oldRule and newRule hash to the same item (e.g. a no-op update).
+		// DynamoDB rejects a transaction with a Delete and a Put on the same
+		// key, and there's nothing to change anyway.
+		return nil
+	}
+
+	err := a.DB.WriteTx().
+		Delete(a.DB.Table(a.DataSourceName).Delete("ID", oldItem.ID).If("attribute_exists(ID)")).
+		Put(a.DB.Table(a.DataSourceName).Put(newItem).If("attribute_not_exists(ID)")).
+		Run(ctx)
+	if err != nil {
+		return updateTransactErr(err, [][]string{oldRule})
+	}
+	return nil
+}
+
+// UpdatePolicies updates a batch of policy rules in storage.
+func (a *Adapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return a.UpdatePoliciesCtx(context.Background(), sec, ptype, oldRules, newRules)
+}
+
+// UpdatePoliciesCtx updates a batch of policy rules with context, chunking
+// into groups that fit DynamoDB's per-transaction item limit.
+func (a *Adapter) UpdatePoliciesCtx(ctx context.Context, sec string, ptype string, oldRules, newRules [][]string) error {
+	if len(oldRules) != len(newRules) {
+		return fmt.Errorf("dynacasbin: oldRules and newRules must be the same length, got %d and %d", len(oldRules), len(newRules))
+	}
+
+	const rulesPerChunk = maxTransactItems / 2
+	for start := 0; start < len(oldRules); start += rulesPerChunk {
+		end := start + rulesPerChunk
+		if end > len(oldRules) {
+			end = len(oldRules)
+		}
+
+		tx := a.DB.WriteTx()
+		for i := start; i < end; i++ {
+			oldItem := savePolicyLine(ptype, oldRules[i])
+			newItem := savePolicyLine(ptype, newRules[i])
+			tx = tx.
+				Delete(a.DB.Table(a.DataSourceName).Delete("ID", oldItem.ID).If("attribute_exists(ID)")).
+				Put(a.DB.Table(a.DataSourceName).Put(newItem).If("attribute_not_exists(ID)"))
+		}
+		if err := tx.Run(ctx); err != nil {
+			return updateTransactErr(err, oldRules[start:end])
+		}
+	}
+	return nil
+}
+
+// UpdateFilteredPolicies replaces every rule matching the filter
+// (fieldIndex, fieldValues) with newRules and reports the old rules that
+// were replaced. The matched rules and newRules are independent sets - the
+// caller removes one and adds the other separately, with no positional
+// correspondence or length requirement between them - so deletes and puts
+// are packed together into transactions sized to DynamoDB's per-transaction
+// item limit, rather than paired up 1:1.
+func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	return a.UpdateFilteredPoliciesCtx(context.Background(), sec, ptype, newRules, fieldIndex, fieldValues...)
+}
+
+// UpdateFilteredPoliciesCtx replaces every rule matching the filter
+// (fieldIndex, fieldValues) with newRules and reports the old rules that
+// were replaced, with context. The matched rules and newRules are
+// independent sets - the caller removes one and adds the other separately,
+// with no positional correspondence or length requirement between them - so
+// deletes and puts are packed together into transactions sized to
+// DynamoDB's per-transaction item limit, rather than paired up 1:1.
+func (a *Adapter) UpdateFilteredPoliciesCtx(ctx context.Context, sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	matched, err := a.loadFilteredItemsCtx(ctx, fieldFilter(ptype, fieldIndex, fieldValues))
+	if err != nil {
+		return nil, err
+	}
+
+	oldRules := make([][]string, len(matched))
+	for i, item := range matched {
+		oldRules[i] = ruleValues(item)
+	}
+
+	if err := a.replaceRulesCtx(ctx, ptype, oldRules, newRules); err != nil {
+		return nil, err
+	}
+	return oldRules, nil
+}
+
+// replaceOp is one row of a replaceRulesCtx transaction: a Delete of an old
+// rule or a Put of a new one, keyed by the row's ID.
+type replaceOp struct {
+	id     string
+	rule   []string
+	delete bool
+}
+
+// planReplaceOps computes the deletes and puts needed to replace oldRules
+// with newRules. A rule present in both (by ID - e.g. replacing a row with
+// an identical one) is left untouched rather than deleted and put in the
+// same transaction, which DynamoDB rejects.
+func planReplaceOps(ptype string, oldRules, newRules [][]string) []replaceOp {
+	deletes := make(map[string][]string, len(oldRules))
+	for _, rule := range oldRules {
+		deletes[savePolicyLine(ptype, rule).ID] = rule
+	}
+	puts := make(map[string][]string, len(newRules))
+	for _, rule := range newRules {
+		puts[savePolicyLine(ptype, rule).ID] = rule
+	}
+	for id := range deletes {
+		if _, ok := puts[id]; ok {
+			delete(deletes, id)
+			delete(puts, id)
+		}
+	}
+
+	ops := make([]replaceOp, 0, len(deletes)+len(puts))
+	for id, rule := range deletes {
+		ops = append(ops, replaceOp{id: id, rule: rule, delete: true})
+	}
+	for id, rule := range puts {
+		ops = append(ops, replaceOp{id: id, rule: rule, delete: false})
+	}
+	return ops
+}
+
+// chunkReplaceOps splits ops into groups of at most maxTransactItems, the
+// most that fit in a single DynamoDB transaction.
+func chunkReplaceOps(ops []replaceOp) [][]replaceOp {
+	var chunks [][]replaceOp
+	for start := 0; start < len(ops); start += maxTransactItems {
+		end := start + maxTransactItems
+		if end > len(ops) {
+			end = len(ops)
+		}
+		chunks = append(chunks, ops[start:end])
+	}
+	return chunks
+}
+
+// replaceRulesCtx deletes oldRules and puts newRules, chunked across as many
+// transactions as needed.
+func (a *Adapter) replaceRulesCtx(ctx context.Context, ptype string, oldRules, newRules [][]string) error {
+	for _, chunk := range chunkReplaceOps(planReplaceOps(ptype, oldRules, newRules)) {
+		tx := a.DB.WriteTx()
+		opRules := make([][]string, len(chunk))
+		for i, o := range chunk {
+			opRules[i] = o.rule
+			if o.delete {
+				tx = tx.Delete(a.DB.Table(a.DataSourceName).Delete("ID", o.id).If("attribute_exists(ID)"))
+			} else {
+				tx = tx.Put(a.DB.Table(a.DataSourceName).Put(savePolicyLine(ptype, o.rule)).If("attribute_not_exists(ID)"))
+			}
+		}
+		if err := tx.Run(ctx); err != nil {
+			return updateFilteredTransactErr(err, opRules)
+		}
+	}
+	return nil
+}
+
+// updateFilteredTransactErr maps a transaction failure back to the rule (in
+// opRules, matching Delete/Put submission order) whose conditional check
+// failed, if any.
+func updateFilteredTransactErr(err error, opRules [][]string) error {
+	var tce *types.TransactionCanceledException
+	if err == nil || !errors.As(err, &tce) {
+		return err
+	}
+	for i, reason := range tce.CancellationReasons {
+		if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+			return &UpdateError{Rule: opRules[i], Err: err}
+		}
+	}
+	return err
+}
+
+// ruleValues extracts the non-PType values of a rule, trimming trailing
+// empty fields the way Casbin policies are normally represented.
+func ruleValues(line CasbinRule) []string {
+	values := []string{line.V0, line.V1, line.V2, line.V3, line.V4, line.V5}
+	end := len(values)
+	for end > 0 && values[end-1] == "" {
+		end--
+	}
+	return values[:end]
+}